@@ -0,0 +1,107 @@
+#!/usr/bin/env bash
+# -------------------------------------------------------
+# Copyright (c) 2025 Arm Limited. All rights reserved.
+#
+# SPDX-License-Identifier: Apache-2.0
+# -------------------------------------------------------
+
+# Runs the "Resolve test matrix" step of build-and-verify.yml against custom
+# test matrices that the self-test workflow does not use, and checks the
+# resolved targets or the reported errors.
+#
+# Usage: scripts/test_resolve_test_matrix.sh
+# Requires bash, jq and yq.
+
+set -uo pipefail
+
+root=$(cd "$(dirname "$0")/.." && pwd)
+work=$(mktemp -d)
+trap 'rm -rf "$work"' EXIT
+
+yq -r '.jobs."configure-ci".steps[] | select(.id == "test-matrix") | .run' \
+  "$root/.github/workflows/build-and-verify.yml" > "$work/resolve.sh"
+if [ ! -s "$work/resolve.sh" ]; then
+  echo "The Resolve test matrix step was not found." >&2
+  exit 1
+fi
+
+failures=0
+
+# resolve runs the step with the given test-matrix input and sets status,
+# targets (the resolved "target-arch" pairs) and log.
+resolve() {
+  : > "$work/output"
+  log=$(TEST_MATRIX="$1" GITHUB_OUTPUT="$work/output" bash "$work/resolve.sh" 2>&1)
+  status=$?
+  targets=$(sed -n 's/^matrix=//p' "$work/output" | jq -r '[.[] | "\(.target)-\(.arch)"] | join(" ")')
+}
+
+# expect_targets checks that a matrix resolves to the given targets.
+expect_targets() {
+  local name=$1 matrix=$2 want=$3
+  resolve "$matrix"
+  if [ "$status" -ne 0 ] || [ "$targets" != "$want" ]; then
+    echo "FAIL $name: got status $status, targets \"$targets\", want \"$want\""
+    echo "$log"
+    failures=$((failures + 1))
+    return
+  fi
+  echo "ok   $name"
+}
+
+# expect_error checks that a matrix is rejected with an error annotation
+# containing the given text.
+expect_error() {
+  local name=$1 matrix=$2 want=$3
+  resolve "$matrix"
+  if [ "$status" -eq 0 ] || ! grep -qF "::error::" <<< "$log" || ! grep -qF "$want" <<< "$log"; then
+    echo "FAIL $name: got status $status, want error containing \"$want\""
+    echo "$log"
+    failures=$((failures + 1))
+    return
+  fi
+  echo "ok   $name"
+}
+
+expect_targets "default matrix" '' \
+  "windows-amd64 windows-arm64 linux-amd64 linux-arm64 darwin-arm64"
+expect_targets "label array, case folded" \
+  '[{"platform":["self-hosted","Linux","ARM64"],"arch":"arm64"}]' "linux-arm64"
+expect_targets "explicit goos" \
+  '[{"platform":"my-runner","goos":"freebsd","arch":"amd64"}]' "freebsd-amd64"
+expect_targets "explicit goos matching the labels" \
+  '[{"platform":"windows-2022","goos":"windows","arch":"amd64"}]' "windows-amd64"
+
+expect_error "invalid JSON" 'not json' "must be a non-empty JSON array"
+expect_error "empty array" '[]' "must be a non-empty JSON array"
+expect_error "entry not an object" '["ubuntu-24.04"]' "entry 1 is not an object"
+expect_error "missing platform" '[{"arch":"amd64"}]' "entry 1 has no platform"
+expect_error "invalid arch" '[{"platform":"ubuntu-24.04","arch":"x86 64"}]' "invalid arch"
+expect_error "unknown goos" \
+  '[{"platform":"my-runner","goos":"ubuntu","arch":"amd64"}]' 'unknown goos "ubuntu"'
+expect_error "goos not matching the labels" \
+  '[{"platform":"windows-2022","goos":"linux","arch":"amd64"}]' "but its platform runs windows"
+expect_error "unresolvable platform" \
+  '[{"platform":"my-runner","arch":"amd64"}]' "Cannot determine GOOS"
+expect_error "ambiguous labels" \
+  '[{"platform":["linux","windows"],"arch":"amd64"}]' "Cannot determine GOOS"
+expect_error "control characters" \
+  '[{"platform":"my-runner","goos":"linux\n","arch":"amd64"}]' "control characters"
+expect_error "duplicate target and arch" \
+  '[{"platform":["self-hosted","linux"],"arch":"arm64"},{"platform":"ubuntu-24.04-arm","arch":"arm64"}]' \
+  "both resolve to linux-arm64"
+
+# All errors are reported in one run.
+resolve '[{"platform":"my-runner","arch":"amd64"},{"platform":"x","goos":"ubuntu","arch":"amd64"}]'
+if [ "$(grep -c '::error::' <<< "$log")" -ne 2 ]; then
+  echo "FAIL all errors reported: want 2 errors"
+  echo "$log"
+  failures=$((failures + 1))
+else
+  echo "ok   all errors reported"
+fi
+
+if [ "$failures" -gt 0 ]; then
+  echo "$failures case(s) failed."
+  exit 1
+fi
@@ -0,0 +1,66 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+package leakcheck
+
+import (
+	"os"
+	"sort"
+)
+
+// openFile is an open file descriptor or handle of the process.
+type openFile struct {
+	// desc describes the file in reports, e.g. "fd 3: /tmp/x".
+	desc string
+	// path resolves to the open file when passed to os.Stat.
+	path string
+}
+
+// fileSet maps descriptors or handles to the files they refer to.
+type fileSet map[uintptr]openFile
+
+// leaked describes the files in after that are not in before, except those
+// that are the same file as one of the ignored paths.
+func (before fileSet) leaked(after fileSet, ignore []string) []string {
+	var ignored []os.FileInfo
+	for _, path := range ignore {
+		if info, err := os.Stat(path); err == nil {
+			ignored = append(ignored, info)
+		}
+	}
+	var ids []uintptr
+	for id, f := range after {
+		if b, ok := before[id]; ok && b.desc == f.desc {
+			continue
+		}
+		if sameFileAny(f.path, ignored) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	open := make([]string, 0, len(ids))
+	for _, id := range ids {
+		open = append(open, after[id].desc)
+	}
+	return open
+}
+
+func sameFileAny(path string, infos []os.FileInfo) bool {
+	if path == "" || len(infos) == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	for _, other := range infos {
+		if os.SameFile(info, other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+//go:build unix
+
+package leakcheck
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var initPoller sync.Once
+
+func openFiles() (fileSet, error) {
+	// The runtime opens descriptors for its network poller on first use and
+	// keeps them; make sure that happens before the first snapshot.
+	initPoller.Do(func() {
+		if r, w, err := os.Pipe(); err == nil {
+			_ = r.Close()
+			_ = w.Close()
+		}
+	})
+
+	dir := "/dev/fd"
+	if runtime.GOOS == "linux" {
+		dir = "/proc/self/fd"
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	self := int(f.Fd())
+	files := make(fileSet, len(names))
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil || fd == self {
+			continue
+		}
+		path := dir + "/" + name
+		target, err := os.Readlink(path)
+		if err != nil {
+			target = "?"
+		}
+		files[uintptr(fd)] = openFile{desc: fmt.Sprintf("fd %d: %s", fd, target), path: path}
+	}
+	return files, nil
+}
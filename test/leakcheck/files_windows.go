@@ -0,0 +1,115 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+//go:build windows
+
+package leakcheck
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procNtQueryInformationProcess = syscall.NewLazyDLL("ntdll.dll").NewProc("NtQueryInformationProcess")
+	procGetFinalPathNameByHandleW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetFinalPathNameByHandleW")
+)
+
+const (
+	processHandleInformation = 51 // PROCESSINFOCLASS, Windows 8 and later
+	statusInfoLengthMismatch = 0xC0000004
+)
+
+// processHandleTableEntryInfo is PROCESS_HANDLE_TABLE_ENTRY_INFO.
+type processHandleTableEntryInfo struct {
+	HandleValue      syscall.Handle
+	HandleCount      uintptr
+	PointerCount     uintptr
+	GrantedAccess    uint32
+	ObjectTypeIndex  uint32
+	HandleAttributes uint32
+	Reserved         uint32
+}
+
+// openFiles lists the handles of the process that refer to files on disk.
+// Handles of other kinds, such as events, threads, pipes or the console, are
+// owned by the runtime or by child processes and are left out.
+func openFiles() (fileSet, error) {
+	handles, err := processHandles()
+	if err != nil {
+		return nil, err
+	}
+	files := make(fileSet)
+	for _, h := range handles {
+		// GetFileType fails for handles that are not files and never blocks,
+		// unlike name queries on pipes.
+		if t, err := syscall.GetFileType(h); err != nil || t != syscall.FILE_TYPE_DISK {
+			continue
+		}
+		path, err := finalPathName(h)
+		if err != nil {
+			// The handle was closed or reused since it was listed.
+			continue
+		}
+		files[uintptr(h)] = openFile{desc: fmt.Sprintf("handle %#x: %s", uintptr(h), path), path: path}
+	}
+	return files, nil
+}
+
+// processHandles returns all handles of the current process.
+func processHandles() ([]syscall.Handle, error) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return nil, err
+	}
+	const ptrSize = unsafe.Sizeof(uintptr(0))
+	size := uint32(16 << 10)
+	for {
+		// A []uintptr keeps the buffer pointer-aligned.
+		buf := make([]uintptr, uintptr(size)/ptrSize)
+		var needed uint32
+		status, _, _ := procNtQueryInformationProcess.Call(uintptr(process), processHandleInformation,
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))*ptrSize, uintptr(unsafe.Pointer(&needed)))
+		if uint32(status) == statusInfoLengthMismatch {
+			size = max(needed, 2*size)
+			continue
+		}
+		if status != 0 {
+			return nil, fmt.Errorf("NtQueryInformationProcess: NTSTATUS %#08x", uint32(status))
+		}
+		// PROCESS_HANDLE_SNAPSHOT_INFORMATION: NumberOfHandles, Reserved, Handles[].
+		n := buf[0]
+		entrySize := unsafe.Sizeof(processHandleTableEntryInfo{})
+		if 2*ptrSize+n*entrySize > uintptr(len(buf))*ptrSize {
+			return nil, fmt.Errorf("NtQueryInformationProcess: %d handles do not fit the buffer", n)
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		entries := unsafe.Slice((*processHandleTableEntryInfo)(unsafe.Pointer(&buf[2])), n)
+		handles := make([]syscall.Handle, 0, n)
+		for _, e := range entries {
+			handles = append(handles, e.HandleValue)
+		}
+		return handles, nil
+	}
+}
+
+// finalPathName returns the path of the file behind h.
+func finalPathName(h syscall.Handle) (string, error) {
+	buf := make([]uint16, syscall.MAX_PATH)
+	for {
+		n, _, err := procGetFinalPathNameByHandleW.Call(uintptr(h), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+		if n == 0 {
+			return "", err
+		}
+		if n < uintptr(len(buf)) {
+			return syscall.UTF16ToString(buf[:n]), nil
+		}
+		buf = make([]uint16, n)
+	}
+}
@@ -0,0 +1,85 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+package leakcheck
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// goroutine is a single goroutine parsed from a stack dump.
+type goroutine struct {
+	id    uint64
+	funcs []string
+	stack string
+}
+
+// calls reports whether any of the named functions is on the stack of g.
+func (g goroutine) calls(names []string) bool {
+	for _, f := range g.funcs {
+		for _, name := range names {
+			if f == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// goroutines returns all goroutines, starting with the calling one.
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var gs []goroutine
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if g, ok := parseGoroutine(stack); ok {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// parseGoroutine parses one block of a runtime.Stack dump:
+//
+//	goroutine 7 [chan receive]:
+//	main.worker(0xc000012345)
+//		/src/main.go:12 +0x25
+//	created by main.start in goroutine 1
+//		/src/main.go:8 +0x3f
+func parseGoroutine(stack string) (goroutine, bool) {
+	stack = strings.TrimSpace(stack)
+	lines := strings.Split(stack, "\n")
+	header, ok := strings.CutPrefix(lines[0], "goroutine ")
+	if !ok {
+		return goroutine{}, false
+	}
+	idStr, _, _ := strings.Cut(header, " ")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return goroutine{}, false
+	}
+	g := goroutine{id: id, stack: stack}
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "created by ") {
+			continue
+		}
+		// Strip the argument list, e.g. "pkg.(*T).f(0x1, 0x2)" -> "pkg.(*T).f".
+		if i := strings.LastIndex(line, "("); i > 0 && strings.HasSuffix(line, ")") {
+			line = line[:i]
+		}
+		g.funcs = append(g.funcs, line)
+	}
+	return g, true
+}
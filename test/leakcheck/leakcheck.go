@@ -0,0 +1,228 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+// Package leakcheck reports goroutines and open files that a test leaves
+// behind. Leaked files keep temporary directories from being removed, and
+// leaked goroutines make later tests flaky.
+//
+// A single test opts in with Check:
+//
+//	func TestSomething(t *testing.T) {
+//		leakcheck.Check(t)
+//		...
+//	}
+//
+// A whole package opts in from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		leakcheck.VerifyTestMain(m)
+//	}
+//
+// A package can have only one TestMain, so an existing test of that name has
+// to be renamed first.
+//
+// Open files are file descriptors on Unix systems and handles of files on disk
+// on Windows. Checks compare the state before and after a test, so they must
+// not be used in tests that run in parallel with others.
+package leakcheck
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// defaultIgnore lists functions of goroutines that are owned by the runtime
+// or the testing package rather than by the code under test.
+var defaultIgnore = []string{
+	"testing.tRunner",
+	"testing.(*T).Run",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+}
+
+type options struct {
+	ignore      []string
+	ignoreFiles []string
+	timeout     time.Duration
+	skipFiles   bool
+}
+
+// Option configures a leak check.
+type Option func(*options)
+
+// IgnoreFunction ignores goroutines that have the fully qualified function
+// name, e.g. "net/http.(*persistConn).readLoop", anywhere on their stack.
+func IgnoreFunction(name string) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, name)
+	}
+}
+
+// IgnoreFile ignores descriptors and handles that refer to the file at path,
+// which need not exist when the check starts.
+func IgnoreFile(path string) Option {
+	return func(o *options) {
+		o.ignoreFiles = append(o.ignoreFiles, path)
+	}
+}
+
+// Timeout sets how long to wait for goroutines to exit and files to be closed
+// before they are reported. The default is two seconds.
+func Timeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// NoFileCheck disables the check for open files.
+func NoFileCheck() Option {
+	return func(o *options) {
+		o.skipFiles = true
+	}
+}
+
+func buildOptions(opts []Option) *options {
+	o := &options{
+		ignore:  append([]string(nil), defaultIgnore...),
+		timeout: 2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Check records the current goroutines and open files and fails t at the end
+// of the test if new ones are still around.
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+	o := buildOptions(opts)
+	before, err := take(o)
+	if err != nil {
+		t.Fatalf("leakcheck: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := before.verify(o); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// VerifyTestMain runs the tests of m and exits with a non-zero status if they
+// leave goroutines or open files behind.
+func VerifyTestMain(m *testing.M, opts ...Option) {
+	o := buildOptions(opts)
+	before, err := take(o)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "leakcheck: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	// The testing package leaves the CPU profile and trace files open.
+	for _, name := range []string{"test.cpuprofile", "test.trace"} {
+		if path := flagValue(name); path != "" {
+			o.ignoreFiles = append(o.ignoreFiles, outputPath(path))
+		}
+	}
+	if code == 0 {
+		if err := before.verify(o); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func flagValue(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// outputPath resolves path like the testing package does for the files it
+// writes, relative to -test.outputdir if that is set.
+func outputPath(path string) string {
+	if dir := flagValue("test.outputdir"); dir != "" && !filepath.IsAbs(path) {
+		return filepath.Join(dir, path)
+	}
+	return path
+}
+
+// snapshot is the state a check compares against.
+type snapshot struct {
+	goroutines map[uint64]bool
+	files      fileSet
+}
+
+func take(o *options) (*snapshot, error) {
+	s := &snapshot{goroutines: make(map[uint64]bool)}
+	for _, g := range goroutines() {
+		s.goroutines[g.id] = true
+	}
+	if !o.skipFiles {
+		files, err := openFiles()
+		if err != nil {
+			return nil, err
+		}
+		s.files = files
+	}
+	return s, nil
+}
+
+// verify retries until the timeout expires and returns an error describing
+// everything that is still leaked.
+func (s *snapshot) verify(o *options) error {
+	deadline := time.Now().Add(o.timeout)
+	delay := time.Millisecond
+	for {
+		err := s.leaks(o)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+func (s *snapshot) leaks(o *options) error {
+	var errs []error
+	var leaked []goroutine
+	for i, g := range goroutines() {
+		// The first goroutine is the one running the check.
+		if i == 0 || s.goroutines[g.id] || g.calls(o.ignore) {
+			continue
+		}
+		leaked = append(leaked, g)
+	}
+	if len(leaked) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "leakcheck: found %d unexpected goroutine(s):", len(leaked))
+		for _, g := range leaked {
+			fmt.Fprintf(&b, "\n\n%s", g.stack)
+		}
+		errs = append(errs, errors.New(b.String()))
+	}
+	if !o.skipFiles {
+		files, err := openFiles()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("leakcheck: %w", err))
+		} else if open := s.files.leaked(files, o.ignoreFiles); len(open) > 0 {
+			errs = append(errs, fmt.Errorf("leakcheck: found %d unexpected open file(s):\n\t%s",
+				len(open), strings.Join(open, "\n\t")))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,188 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+package leakcheck
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timeoutEnv overrides the timeout of the check in TestMain, so that
+// TestVerifyTestMainProfiles sees files that are left open at once.
+const timeoutEnv = "LEAKCHECK_TEST_TIMEOUT"
+
+func TestMain(m *testing.M) {
+	var opts []Option
+	if d, err := time.ParseDuration(os.Getenv(timeoutEnv)); err == nil {
+		opts = append(opts, Timeout(d))
+	}
+	VerifyTestMain(m, opts...)
+}
+
+func blockedWorker(stop <-chan struct{}, done chan<- struct{}) {
+	<-stop
+	close(done)
+}
+
+func TestGoroutineLeak(t *testing.T) {
+	o := buildOptions([]Option{Timeout(50 * time.Millisecond)})
+	before, err := take(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go blockedWorker(stop, done)
+	err = before.verify(o)
+	if err == nil || !strings.Contains(err.Error(), "leakcheck.blockedWorker") {
+		t.Errorf("verify() = %v, want leaked blockedWorker", err)
+	}
+
+	ignored := buildOptions([]Option{Timeout(50 * time.Millisecond),
+		IgnoreFunction("github.com/open-cmsis-pack/sample/leakcheck.blockedWorker")})
+	if err := before.verify(ignored); err != nil {
+		t.Errorf("verify() with ignored function = %v, want nil", err)
+	}
+
+	close(stop)
+	<-done
+	if err := before.verify(o); err != nil {
+		t.Errorf("verify() after goroutine exit = %v, want nil", err)
+	}
+}
+
+func TestFileLeak(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skipf("open files are not checked on %s", runtime.GOOS)
+	}
+	o := buildOptions([]Option{Timeout(50 * time.Millisecond)})
+	before, err := take(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "leak.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = before.verify(o)
+	if err == nil || !strings.Contains(err.Error(), "open file") {
+		t.Errorf("verify() = %v, want open file", err)
+	}
+	if err := before.verify(buildOptions([]Option{NoFileCheck()})); err != nil {
+		t.Errorf("verify() with NoFileCheck = %v, want nil", err)
+	}
+	if err := before.verify(buildOptions([]Option{IgnoreFile(f.Name())})); err != nil {
+		t.Errorf("verify() with IgnoreFile = %v, want nil", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.verify(o); err != nil {
+		t.Errorf("verify() after close = %v, want nil", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	Check(t)
+	done := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(done)
+	}()
+	// The goroutine is still running when the test returns; Check waits for it.
+}
+
+func TestParseGoroutine(t *testing.T) {
+	stack := "goroutine 7 [chan receive]:\n" +
+		"example.com/pkg.(*T).worker(0xc000012345, 0x1)\n" +
+		"\t/src/pkg/worker.go:12 +0x25\n" +
+		"created by example.com/pkg.start in goroutine 1\n" +
+		"\t/src/pkg/worker.go:8 +0x3f\n"
+	g, ok := parseGoroutine(stack)
+	if !ok {
+		t.Fatal("parseGoroutine() failed")
+	}
+	if g.id != 7 {
+		t.Errorf("id = %d, want 7", g.id)
+	}
+	if len(g.funcs) != 1 || g.funcs[0] != "example.com/pkg.(*T).worker" {
+		t.Errorf("funcs = %q, want [example.com/pkg.(*T).worker]", g.funcs)
+	}
+	if !g.calls([]string{"example.com/pkg.(*T).worker"}) {
+		t.Error("calls() = false, want true")
+	}
+
+	if _, ok := parseGoroutine("not a stack"); ok {
+		t.Error("parseGoroutine() of garbage succeeded")
+	}
+}
+
+// fakeTB records the calls Check makes on a testing.TB.
+type fakeTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func (f *fakeTB) Error(args ...any) {
+	f.errors = append(f.errors, fmt.Sprint(args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+func TestCheckReportsLeak(t *testing.T) {
+	fake := &fakeTB{}
+	Check(fake, Timeout(50*time.Millisecond))
+	if len(fake.cleanups) != 1 {
+		t.Fatalf("Check registered %d cleanups, want 1", len(fake.cleanups))
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go blockedWorker(stop, done)
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	fake.cleanups[0]()
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "leakcheck.blockedWorker") {
+		t.Errorf("Check reported %q, want leaked blockedWorker", fake.errors)
+	}
+}
+
+// TestVerifyTestMainProfiles runs this package's tests again with the flags
+// that make the testing package leave files open.
+func TestVerifyTestMainProfiles(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, flag := range []string{"-test.cpuprofile=cpu.out", "-test.trace=trace.out"} {
+		cmd := exec.Command(self, "-test.run=^TestParseGoroutine$", "-test.outputdir="+dir, flag)
+		cmd.Env = append(os.Environ(), timeoutEnv+"=0s")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%s: %v\n%s", flag, err, out)
+		}
+	}
+}
@@ -6,9 +6,17 @@
 
 package main
 
-import "testing"
+import (
+	"testing"
 
-func TestMain(t *testing.T) {
+	"github.com/open-cmsis-pack/sample/leakcheck"
+)
+
+func TestMain(m *testing.M) {
+	leakcheck.VerifyTestMain(m)
+}
+
+func TestGreeting(t *testing.T) {
 	// Example test
 	got := "Hello, workflows-and-actions-collection!"
 	want := "Hello, workflows-and-actions-collection!"
@@ -0,0 +1,518 @@
+// -------------------------------------------------------
+// Copyright (c) 2025 Arm Limited. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+// -------------------------------------------------------
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/open-cmsis-pack/sample/leakcheck"
+)
+
+const (
+	// runAsTestapp is set in the environment of commands started by a script,
+	// so the test binary behaves like the testapp program instead of running
+	// tests.
+	runAsTestapp = "TESTAPP_RUN_MAIN"
+	// testappExitStatus makes testapp exit with the given status after main
+	// returns, so scripts can cover "! exec".
+	testappExitStatus = "TESTAPP_EXIT_STATUS"
+)
+
+func init() {
+	if os.Getenv(runAsTestapp) == "1" {
+		main()
+		code, _ := strconv.Atoi(os.Getenv(testappExitStatus))
+		os.Exit(code)
+	}
+}
+
+// TestScripts runs every testdata/script/*.txtar file as a subtest.
+//
+// The comment section of an archive holds the script, one command per line.
+// The files of the archive are extracted into an empty work directory before
+// the script starts. Supported commands:
+//
+//	exec prog [args...]   run prog; "testapp" runs this module's main and
+//	                      exits with $TESTAPP_EXIT_STATUS, 0 if unset
+//	stdout regexp         the last exec's stdout matches regexp
+//	stderr regexp         the last exec's stderr matches regexp
+//	cmp file1 file2       files are identical; file1 may be stdout or stderr
+//	exists file           file exists
+//	env KEY=VALUE         set an environment variable for later commands
+//	cd dir                change the working directory
+//
+// exec, stdout, stderr and exists may be prefixed with "!" to negate them;
+// "! exec" expects the program to start and exit with a non-zero status.
+func TestScripts(t *testing.T) {
+	leakcheck.Check(t)
+	files, err := filepath.Glob(filepath.Join("testdata", "script", "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scripts found in testdata/script")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".txtar")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newScript(t, self).run(file, data)
+		})
+	}
+}
+
+// script holds the state of a single running script.
+type script struct {
+	t      *testing.T
+	self   string
+	work   string
+	dir    string
+	env    []string
+	stdout string
+	stderr string
+}
+
+func newScript(t *testing.T, self string) *script {
+	work := t.TempDir()
+	s := &script{t: t, self: self, work: work, dir: work}
+	s.env = []string{
+		"WORK=" + work,
+		"HOME=" + filepath.Join(work, ".home"),
+		"TMPDIR=" + filepath.Join(work, ".tmp"),
+		"PATH=" + os.Getenv("PATH"),
+	}
+	if runtime.GOOS == "windows" {
+		s.env = append(s.env,
+			"SYSTEMROOT="+os.Getenv("SYSTEMROOT"),
+			"USERPROFILE="+filepath.Join(work, ".home"),
+			"TMP="+filepath.Join(work, ".tmp"),
+			"TEMP="+filepath.Join(work, ".tmp"))
+	}
+	for _, dir := range []string{".home", ".tmp"} {
+		if err := os.Mkdir(filepath.Join(work, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func (s *script) run(file string, data []byte) {
+	comment, archive := parseTxtar(data)
+	for _, f := range archive {
+		path := filepath.Join(s.work, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			s.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, f.data, 0o600); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	for i, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.t.Logf("> %s", line)
+		if err := s.exec(line); err != nil {
+			s.t.Fatalf("%s:%d: %v", file, i+1, err)
+		}
+	}
+}
+
+// exec runs a single script line.
+func (s *script) exec(line string) error {
+	neg := false
+	if strings.HasPrefix(line, "!") {
+		neg = true
+		line = strings.TrimSpace(line[1:])
+	}
+	args, err := s.fields(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing command")
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "exec":
+		return s.cmdExec(neg, args)
+	case "stdout":
+		return matchOutput(neg, "stdout", s.stdout, args)
+	case "stderr":
+		return matchOutput(neg, "stderr", s.stderr, args)
+	case "cmp":
+		return s.cmdCmp(neg, args)
+	case "exists":
+		return s.cmdExists(neg, args)
+	case "env":
+		return s.cmdEnv(neg, args)
+	case "cd":
+		return s.cmdCd(neg, args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func (s *script) cmdExec(neg bool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: exec program [args...]")
+	}
+	prog, env := args[0], s.env
+	if prog == "testapp" {
+		prog, env = s.self, append(env[:len(env):len(env)], runAsTestapp+"=1")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(prog, args[1:]...)
+	cmd.Dir = s.dir
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	s.stdout, s.stderr = stdout.String(), stderr.String()
+	if s.stdout != "" {
+		s.t.Logf("[stdout]\n%s", s.stdout)
+	}
+	if s.stderr != "" {
+		s.t.Logf("[stderr]\n%s", s.stderr)
+	}
+	// Only a non-zero exit status counts as the expected failure; a program
+	// that cannot be started is always an error.
+	var exitErr *exec.ExitError
+	switch {
+	case neg && err == nil:
+		return fmt.Errorf("unexpected success of %s", args[0])
+	case neg && errors.As(err, &exitErr):
+		return nil
+	case err != nil:
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	return nil
+}
+
+func matchOutput(neg bool, name, out string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s regexp", name)
+	}
+	re, err := regexp.Compile("(?m)" + args[0])
+	if err != nil {
+		return err
+	}
+	switch found := re.MatchString(out); {
+	case neg && found:
+		return fmt.Errorf("unexpected match for %#q in %s", args[0], name)
+	case !neg && !found:
+		return fmt.Errorf("no match for %#q in %s", args[0], name)
+	}
+	return nil
+}
+
+func (s *script) cmdCmp(neg bool, args []string) error {
+	if neg {
+		return fmt.Errorf("unsupported: ! cmp")
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: cmp file1 file2")
+	}
+	got, err := s.readFile(args[0])
+	if err != nil {
+		return err
+	}
+	want, err := s.readFile(args[1])
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%s and %s differ:\n--- %s\n%s--- %s\n%s", args[0], args[1], args[0], got, args[1], want)
+	}
+	return nil
+}
+
+func (s *script) cmdExists(neg bool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: exists file...")
+	}
+	for _, name := range args {
+		_, err := os.Stat(s.path(name))
+		switch {
+		case neg && err == nil:
+			return fmt.Errorf("%s unexpectedly exists", name)
+		case !neg && err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *script) cmdEnv(neg bool, args []string) error {
+	if neg {
+		return fmt.Errorf("unsupported: ! env")
+	}
+	for _, kv := range args {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("usage: env KEY=VALUE...")
+		}
+		s.env = append(s.env, kv)
+	}
+	return nil
+}
+
+func (s *script) cmdCd(neg bool, args []string) error {
+	if neg {
+		return fmt.Errorf("unsupported: ! cd")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: cd dir")
+	}
+	dir := s.path(args[0])
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+	s.dir = dir
+	return nil
+}
+
+// readFile returns the contents of name, or the output of the last exec for
+// "stdout" and "stderr".
+func (s *script) readFile(name string) (string, error) {
+	switch name {
+	case "stdout":
+		return s.stdout, nil
+	case "stderr":
+		return s.stderr, nil
+	}
+	data, err := os.ReadFile(s.path(name))
+	return string(data), err
+}
+
+func (s *script) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+// getenv looks up key in the script environment, the last setting wins.
+func (s *script) getenv(key string) string {
+	for i := len(s.env) - 1; i >= 0; i-- {
+		if k, v, _ := strings.Cut(s.env[i], "="); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// fields splits line into words. Text in single quotes is taken literally,
+// where a doubled quote stands for a literal one; elsewhere $VAR and ${VAR}
+// are expanded from the script environment.
+func (s *script) fields(line string) ([]string, error) {
+	var (
+		args   []string
+		word   strings.Builder
+		inWord bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\'':
+			inWord = true
+			for i++; ; i++ {
+				if i >= len(line) {
+					return nil, fmt.Errorf("unterminated quote")
+				}
+				if line[i] == '\'' {
+					if i+1 < len(line) && line[i+1] == '\'' {
+						word.WriteByte('\'')
+						i++
+						continue
+					}
+					break
+				}
+				word.WriteByte(line[i])
+			}
+		case c == ' ' || c == '\t':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			j := i
+			for j < len(line) && line[j] != ' ' && line[j] != '\t' && line[j] != '\'' {
+				j++
+			}
+			word.WriteString(os.Expand(line[i:j], s.getenv))
+			inWord = true
+			i = j - 1
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// txtarFile is a single file of a txtar archive.
+type txtarFile struct {
+	name string
+	data []byte
+}
+
+// parseTxtar splits a txtar archive into its leading comment and files.
+// See golang.org/x/tools/txtar for the format.
+func parseTxtar(data []byte) (string, []txtarFile) {
+	var (
+		comment strings.Builder
+		files   []txtarFile
+	)
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		trimmed := strings.TrimRight(line, "\n")
+		if strings.HasPrefix(trimmed, "-- ") && strings.HasSuffix(trimmed, " --") && len(trimmed) > 6 {
+			name := strings.TrimSpace(trimmed[3 : len(trimmed)-3])
+			files = append(files, txtarFile{name: name})
+			continue
+		}
+		if len(files) == 0 {
+			comment.WriteString(line)
+			continue
+		}
+		last := &files[len(files)-1]
+		last.data = append(last.data, line...)
+	}
+	return comment.String(), files
+}
+
+func TestParseTxtar(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantComment string
+		wantFiles   []txtarFile
+	}{
+		{"comment only", "exec testapp\n", "exec testapp\n", nil},
+		{"files", "exec testapp\n-- a.txt --\nA\n-- dir/b.txt --\nB1\nB2\n",
+			"exec testapp\n", []txtarFile{{"a.txt", []byte("A\n")}, {"dir/b.txt", []byte("B1\nB2\n")}}},
+		{"empty file", "-- empty --\n-- next --\nx\n",
+			"", []txtarFile{{"empty", nil}, {"next", []byte("x\n")}}},
+		{"crlf", "exec testapp\r\n-- a.txt --\r\nA\r\n",
+			"exec testapp\n", []txtarFile{{"a.txt", []byte("A\n")}}},
+		{"not a marker", "-- --\n--a.txt--\n", "-- --\n--a.txt--\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment, files := parseTxtar([]byte(tt.data))
+			if comment != tt.wantComment {
+				t.Errorf("comment = %q, want %q", comment, tt.wantComment)
+			}
+			if len(files) != len(tt.wantFiles) {
+				t.Fatalf("got %d files, want %d", len(files), len(tt.wantFiles))
+			}
+			for i, f := range files {
+				want := tt.wantFiles[i]
+				if f.name != want.name || string(f.data) != string(want.data) {
+					t.Errorf("file %d = %q %q, want %q %q", i, f.name, f.data, want.name, want.data)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptFields(t *testing.T) {
+	s := &script{env: []string{"WORK=/work", "NAME=a b"}}
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"exec testapp  arg\targ2", []string{"exec", "testapp", "arg", "arg2"}, false},
+		{"stdout 'hello world'", []string{"stdout", "hello world"}, false},
+		{"stdout 'it''s'", []string{"stdout", "it's"}, false},
+		{"stdout ''", []string{"stdout", ""}, false},
+		{"cmp pre'fix 'suffix", []string{"cmp", "prefix suffix"}, false},
+		{"exists $WORK/x ${WORK}y", []string{"exists", "/work/x", "/worky"}, false},
+		{"exists $NAME", []string{"exists", "a b"}, false},
+		{"stdout '$WORK'", []string{"stdout", "$WORK"}, false},
+		{"exists $UNSET/x", []string{"exists", "/x"}, false},
+		{"stdout 'unterminated", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := s.fields(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("fields(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("fields(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// TestScriptErrors checks that commands whose expectation is not met fail
+// the script.
+func TestScriptErrors(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		lines   []string
+		wantErr string
+	}{
+		{"stdout no match", []string{"exec testapp", "stdout '^Goodbye'"}, "no match"},
+		{"negated stdout match", []string{"exec testapp", "! stdout Hello"}, "unexpected match"},
+		{"stderr no match", []string{"exec testapp", "stderr ."}, "no match"},
+		{"cmp differ", []string{"exec testapp", "cmp stdout want.txt"}, "differ"},
+		{"cmp missing file", []string{"cmp want.txt missing.txt"}, "missing.txt"},
+		{"exists missing", []string{"exists missing.txt"}, "missing.txt"},
+		{"negated exists", []string{"! exists want.txt"}, "unexpectedly exists"},
+		{"exec failure", []string{"env TESTAPP_EXIT_STATUS=1", "exec testapp"}, "exit status 1"},
+		{"negated exec success", []string{"! exec testapp"}, "unexpected success"},
+		{"negated exec missing program", []string{"! exec no-such-program"}, "no-such-program"},
+		{"negated cmp", []string{"! cmp want.txt want.txt"}, "unsupported"},
+		{"unknown command", []string{"frobnicate"}, "unknown command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScript(t, self)
+			if err := os.WriteFile(filepath.Join(s.work, "want.txt"), []byte("other\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			for _, line := range tt.lines {
+				if err = s.exec(line); err != nil {
+					break
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}